@@ -1,15 +1,20 @@
 package msgpack
 
 import (
+	"container/list"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
+	"sync"
 
 	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
+// minInternedStringLen and maxDictLen are the package-wide defaults used
+// when an Encoder/Decoder hasn't called SetInternMinLen/SetInternMaxDict.
 const (
 	minInternedStringLen = 3
 	maxDictLen           = math.MaxUint16
@@ -17,11 +22,30 @@ const (
 
 var internedStringExtID = int8(math.MinInt8)
 
+// internedStringResetExtID marks a "dictionary reset" record: an evicted
+// intern slot being reassigned to a new string. See
+// (*Encoder).SetInternCache and (*Encoder).encodeInternedStringReset.
+var internedStringResetExtID = int8(math.MinInt8 + 2)
+
+// internedBytesExtID is the []byte/Bin* counterpart of internedStringExtID.
+// The wire shape of the index payload is identical to the string case, so
+// encodeInternedStringIndex/decodeInternedStringIndex are reused for it;
+// only this type discriminator differs.
+var internedBytesExtID = int8(math.MinInt8 + 1)
+
 func init() {
 	extTypes[internedStringExtID] = &extInfo{
 		Type:    stringType,
 		Decoder: decodeInternedStringExt,
 	}
+	extTypes[internedStringResetExtID] = &extInfo{
+		Type:    stringType,
+		Decoder: decodeInternedStringResetExt,
+	}
+	extTypes[internedBytesExtID] = &extInfo{
+		Type:    bytesType,
+		Decoder: decodeInternedBytesExt,
+	}
 }
 
 func decodeInternedStringExt(d *Decoder, v reflect.Value, extLen int) error {
@@ -39,10 +63,371 @@ func decodeInternedStringExt(d *Decoder, v reflect.Value, extLen int) error {
 	return nil
 }
 
+func decodeInternedBytesExt(d *Decoder, v reflect.Value, extLen int) error {
+	idx, err := d.decodeInternedStringIndex(extLen)
+	if err != nil {
+		return err
+	}
+
+	b, err := d.internedBytesAtIndex(idx)
+	if err != nil {
+		return err
+	}
+
+	v.SetBytes(b)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Dictionary is a preloaded table of interned strings that can be seeded
+// into an Encoder and a Decoder so both sides agree on the same index
+// assignment without the strings ever appearing on the wire. It is meant to
+// be built once from a known schema (field names, tag keys, enum values)
+// and shared by many encoders/decoders, so its read path is safe for
+// concurrent use.
+type Dictionary struct {
+	mu      sync.RWMutex
+	strings []string
+	index   map[string]int
+}
+
+// NewDictionary returns an empty Dictionary.
+func NewDictionary() *Dictionary {
+	return &Dictionary{
+		index: make(map[string]int),
+	}
+}
+
+// Add inserts s into the dictionary if it isn't already present and returns
+// its index.
+func (d *Dictionary) Add(s string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if idx, ok := d.index[s]; ok {
+		return idx
+	}
+
+	idx := len(d.strings)
+	d.strings = append(d.strings, s)
+	d.index[s] = idx
+	return idx
+}
+
+// Lookup returns the index assigned to s, if any.
+func (d *Dictionary) Lookup(s string) (int, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	idx, ok := d.index[s]
+	return idx, ok
+}
+
+// At returns the string stored at idx, if any.
+func (d *Dictionary) At(idx int) (string, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if idx < 0 || idx >= len(d.strings) {
+		return "", false
+	}
+	return d.strings[idx], true
+}
+
+// Dump serializes the dictionary as a msgpack array of strings so a
+// producer and consumer can agree on its contents out of band. It is not an
+// io.WriterTo: unlike that interface's WriteTo, it reports only an error,
+// not a byte count.
+func (d *Dictionary) Dump(w io.Writer) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return NewEncoder(w).Encode(d.strings)
+}
+
+// NewDictionaryFromReader reads a Dictionary previously written with
+// (*Dictionary).Dump.
+func NewDictionaryFromReader(r io.Reader) (*Dictionary, error) {
+	var strings []string
+	if err := NewDecoder(r).Decode(&strings); err != nil {
+		return nil, err
+	}
+
+	dict := NewDictionary()
+	for _, s := range strings {
+		dict.Add(s)
+	}
+	return dict, nil
+}
+
+// SetSharedDict preloads e's intern table with dict's contents, indexed
+// identically to the order strings were Add'ed, so strings already known to
+// both sides are encoded by index from the very first occurrence instead of
+// paying the ext-header cost on first use.
+func (e *Encoder) SetSharedDict(dict *Dictionary) {
+	if dict == nil {
+		return
+	}
+
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+
+	local := &localEncoderDict{
+		m:   make(map[string]int, len(dict.strings)),
+		rev: append([]string(nil), dict.strings...),
+	}
+	for idx, s := range dict.strings {
+		local.m[s] = idx
+	}
+	e.dict = local
+}
+
+// SetSharedDict preloads d's intern table with dict's contents, mirroring
+// (*Encoder).SetSharedDict so both sides assign identical indexes.
+func (d *Decoder) SetSharedDict(dict *Dictionary) {
+	if dict == nil {
+		return
+	}
+
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+
+	d.dict = &localDecoderDict{s: append([]string(nil), dict.strings...)}
+}
+
+// EncoderDict is the backend behind an Encoder's intern table. The default,
+// installed lazily the first time a string is interned, is an in-memory map
+// (NewLocalEncoderDict). Callers that want multiple encoders serving the
+// same schema to share indexing state - for example a cache shared across
+// goroutines, or an out-of-process cache - can call SetDict with their own
+// implementation instead.
+// EncoderDict is consulted only for interned strings (see SetDict); interned
+// []byte values always use e.bdict and have no pluggable equivalent.
+type EncoderDict interface {
+	// Intern returns the index assigned to s, interning it if it wasn't
+	// already present. existed reports whether s was already interned.
+	Intern(s string) (idx int, existed bool)
+	// Lookup returns the index assigned to s without interning it, so a
+	// field that opted out of adding new entries (e.g. because it's past
+	// capacity, or not meant to grow the dictionary) can still reference an
+	// entry some other field already interned.
+	Lookup(s string) (idx int, ok bool)
+	// Len reports how many strings are currently interned.
+	Len() int
+}
+
+// SetDict installs dict as e's intern table backend, replacing the default
+// in-memory map. Note that (*Encoder).SetInternCache's capacity/eviction
+// behavior is only honored for the default backend; a custom dict is
+// expected to own its own bounding strategy.
+func (e *Encoder) SetDict(dict EncoderDict) {
+	e.dict = dict
+}
+
+// localEncoderDict is the default EncoderDict. It additionally exposes the
+// lookup/insert/reassign primitives (*Encoder).encodeInternedString uses to
+// implement SetInternCache's capacity and eviction bookkeeping.
+type localEncoderDict struct {
+	m   map[string]int
+	rev []string
+}
+
+// NewLocalEncoderDict returns the default in-process EncoderDict.
+func NewLocalEncoderDict() EncoderDict {
+	return &localEncoderDict{m: make(map[string]int)}
+}
+
+func (d *localEncoderDict) Intern(s string) (int, bool) {
+	if idx, ok := d.lookup(s); ok {
+		return idx, true
+	}
+	return d.insert(s), false
+}
+
+func (d *localEncoderDict) Len() int {
+	return len(d.rev)
+}
+
+// Lookup satisfies EncoderDict for external callers; the fast path in
+// (*Encoder).encodeInternedString calls the unexported lookup directly.
+func (d *localEncoderDict) Lookup(s string) (int, bool) {
+	return d.lookup(s)
+}
+
+func (d *localEncoderDict) lookup(s string) (int, bool) {
+	idx, ok := d.m[s]
+	return idx, ok
+}
+
+func (d *localEncoderDict) insert(s string) int {
+	idx := len(d.rev)
+	d.m[s] = idx
+	d.rev = append(d.rev, s)
+	return idx
+}
+
+func (d *localEncoderDict) reassign(idx int, s string) {
+	delete(d.m, d.rev[idx])
+	d.m[s] = idx
+	d.rev[idx] = s
+}
+
+// DecoderDict is the backend behind a Decoder's intern table, mirroring
+// EncoderDict. The default, installed lazily, is an in-memory slice
+// (NewLocalDecoderDict); see (*Decoder).SetDict to plug in another.
+type DecoderDict interface {
+	// Append registers s as the next index and returns that index.
+	Append(s string) int
+	// Get returns the string at idx, if any.
+	Get(idx int) (string, bool)
+	// Set overwrites idx with s, growing the table if necessary; used to
+	// honor dictionary reset records (see decodeInternedStringResetExt).
+	Set(idx int, s string)
+	// Len reports how many strings are currently registered.
+	Len() int
+}
+
+// SetDict installs dict as d's intern table backend, replacing the default
+// in-memory slice.
+func (d *Decoder) SetDict(dict DecoderDict) {
+	d.dict = dict
+}
+
+// localDecoderDict is the default DecoderDict: a plain in-memory slice.
+type localDecoderDict struct {
+	s []string
+}
+
+// NewLocalDecoderDict returns the default in-process DecoderDict.
+func NewLocalDecoderDict() DecoderDict {
+	return &localDecoderDict{}
+}
+
+func (d *localDecoderDict) Append(s string) int {
+	idx := len(d.s)
+	d.s = append(d.s, s)
+	return idx
+}
+
+func (d *localDecoderDict) Get(idx int) (string, bool) {
+	if idx < 0 || idx >= len(d.s) {
+		return "", false
+	}
+	return d.s[idx], true
+}
+
+func (d *localDecoderDict) Set(idx int, s string) {
+	for len(d.s) <= idx {
+		d.s = append(d.s, "")
+	}
+	d.s[idx] = s
+}
+
+func (d *localDecoderDict) Len() int {
+	return len(d.s)
+}
+
 //------------------------------------------------------------------------------
 
 var errUnexpectedCode = errors.New("msgpack: unexpected code")
 
+// Policy decides which slot of an Encoder's intern table is reused once the
+// table has reached its configured capacity. See (*Encoder).SetInternCache.
+type Policy interface {
+	// Touch records that idx was just inserted into or referenced from the
+	// intern table.
+	Touch(idx int)
+	// Evict returns the index of the slot to reuse for the next new string.
+	Evict() int
+}
+
+// lruPolicy is the default Policy: pure least-recently-used.
+type lruPolicy struct {
+	order *list.List
+	elems map[int]*list.Element
+}
+
+// NewLRUPolicy returns a Policy that evicts the least-recently-used intern
+// slot, for use with (*Encoder).SetInternCache.
+func NewLRUPolicy() Policy {
+	return newLRUPolicy()
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{
+		order: list.New(),
+		elems: make(map[int]*list.Element),
+	}
+}
+
+func (p *lruPolicy) Touch(idx int) {
+	if elem, ok := p.elems[idx]; ok {
+		p.order.MoveToFront(elem)
+		return
+	}
+	p.elems[idx] = p.order.PushFront(idx)
+}
+
+func (p *lruPolicy) Evict() int {
+	back := p.order.Back()
+	idx := back.Value.(int)
+	p.order.Remove(back)
+	delete(p.elems, idx)
+	return idx
+}
+
+// SetInternCache bounds e's interned *string* table (e.dict) to capacity
+// entries and, once it is full, uses policy to pick which slot to reuse for
+// the next new string instead of refusing to intern further strings.
+// Without a call to SetInternCache the table simply grows up to maxDictLen
+// and stops interning once full, as before. This lets a long-lived Encoder
+// whose hot set of strings drifts over time (e.g. a streaming log shipper)
+// keep benefiting from interning instead of hitting a hard cliff at the
+// first maxDictLen distinct strings.
+//
+// It does not apply to the separate interned []byte table (e.bdict, see
+// encodeInternedBytes): binary values share internCap's capacity number but
+// get neither eviction nor a Policy of their own, and fill up permanently
+// once that capacity is reached.
+func (e *Encoder) SetInternCache(capacity int, policy Policy) {
+	e.internCapacity = capacity
+	e.internPolicy = policy
+}
+
+func (e *Encoder) internCap() int {
+	if e.internCapacity > 0 {
+		return e.internCapacity
+	}
+	if e.internMaxDict > 0 {
+		return e.internMaxDict
+	}
+	return maxDictLen
+}
+
+func (e *Encoder) internMinLenOrDefault() int {
+	if e.internMinLen > 0 {
+		return e.internMinLen
+	}
+	return minInternedStringLen
+}
+
+// SetInternMinLen overrides minInternedStringLen for e: strings shorter than
+// n are never interned, no matter how often they repeat. Pass 0 to restore
+// the package default.
+func (e *Encoder) SetInternMinLen(n int) {
+	e.internMinLen = n
+}
+
+// SetInternMaxDict overrides maxDictLen for e, bounding how many distinct
+// strings its intern table holds before new strings stop being interned (or,
+// if SetInternCache was called with a policy but a capacity <= 0, before that
+// Policy starts evicting entries). A positive SetInternCache capacity takes
+// precedence over this limit. Pass 0 to restore the package default.
+func (e *Encoder) SetInternMaxDict(n int) {
+	e.internMaxDict = n
+}
+
 func encodeInternedInterfaceValue(e *Encoder, v reflect.Value) error {
 	if v.IsNil() {
 		return e.EncodeNil()
@@ -61,46 +446,136 @@ func encodeInternedStringValue(e *Encoder, v reflect.Value) error {
 
 func (e *Encoder) encodeInternedString(s string, intern bool) error {
 	// Interned string takes at least 3 bytes. Plain string 1 byte + string length.
-	if len(s) >= minInternedStringLen {
-		if idx, ok := e.dict[s]; ok {
-			return e.encodeInternedStringIndex(idx)
+	if len(s) >= e.internMinLenOrDefault() {
+		if e.dict == nil {
+			e.dict = NewLocalEncoderDict()
 		}
 
-		if intern && len(e.dict) < maxDictLen {
-			if e.dict == nil {
-				e.dict = make(map[string]int)
+		if local, ok := e.dict.(*localEncoderDict); ok {
+			// Fast path: the default backend additionally supports the
+			// capacity/eviction bookkeeping behind SetInternCache.
+			if idx, ok := local.lookup(s); ok {
+				if e.internPolicy != nil {
+					e.internPolicy.Touch(idx)
+				}
+				return e.encodeInternedStringIndex(idx)
+			}
+
+			if intern {
+				if local.Len() < e.internCap() {
+					idx := local.insert(s)
+					if e.internPolicy != nil {
+						e.internPolicy.Touch(idx)
+					}
+				} else if e.internPolicy != nil {
+					idx := e.internPolicy.Evict()
+					local.reassign(idx, s)
+					e.internPolicy.Touch(idx)
+					return e.encodeInternedStringReset(idx, s)
+				}
+			}
+		} else {
+			// A caller-supplied EncoderDict (see SetDict) owns its own
+			// capacity and eviction strategy; SetInternCache only governs
+			// the default local backend. Lookup is safe to call regardless
+			// of intern so a field that opted out of growing the dictionary
+			// still references what another field already interned.
+			if idx, ok := e.dict.Lookup(s); ok {
+				if e.internPolicy != nil {
+					e.internPolicy.Touch(idx)
+				}
+				return e.encodeInternedStringIndex(idx)
+			}
+
+			if intern {
+				e.dict.Intern(s)
 			}
-			idx := len(e.dict)
-			e.dict[s] = idx
 		}
 	}
 
 	return e.encodeNormalString(s)
 }
 
+// encodeInternedStringReset emits a "dictionary reset" ext record telling
+// the decoder to rewrite its intern table at idx to s, mirroring the slot
+// reuse chosen by e.internPolicy on encode-side capacity overflow.
+func (e *Encoder) encodeInternedStringReset(idx int, s string) error {
+	payload := make([]byte, 4+len(s))
+	binary.BigEndian.PutUint32(payload, uint32(idx))
+	copy(payload[4:], s)
+
+	if err := e.EncodeExtHeader(internedStringResetExtID, len(payload)); err != nil {
+		return err
+	}
+	return e.write(payload)
+}
+
+func encodeInternedBytesValue(e *Encoder, v reflect.Value) error {
+	return e.encodeInternedBytes(v.Bytes(), true)
+}
+
+// encodeInternedBytes mirrors encodeInternedString for []byte/Bin8/16/32
+// payloads such as content hashes, raw UUIDs, and repeated protocol tokens,
+// which would otherwise pay full length-prefix + payload cost on every
+// occurrence. It keeps its own table (e.bdict) so binary and string values
+// never collide on the same index space.
+//
+// Unlike e.dict, e.bdict is always the built-in map: it has no EncoderDict
+// equivalent to plug in and, despite reusing e.internCap() as its capacity,
+// no Policy-driven eviction, so it simply stops interning new payloads once
+// that capacity is reached.
+func (e *Encoder) encodeInternedBytes(b []byte, intern bool) error {
+	if len(b) >= e.internMinLenOrDefault() {
+		s := string(b)
+		if idx, ok := e.bdict[s]; ok {
+			return e.encodeInternedBytesIndex(idx)
+		}
+
+		if intern && len(e.bdict) < e.internCap() {
+			if e.bdict == nil {
+				e.bdict = make(map[string]int)
+			}
+			idx := len(e.bdict)
+			e.bdict[s] = idx
+		}
+	}
+
+	return e.encodeNormalBytes(b)
+}
+
 func (e *Encoder) encodeInternedStringIndex(idx int) error {
+	return e.encodeInternedIndex(internedStringExtID, idx)
+}
+
+func (e *Encoder) encodeInternedBytesIndex(idx int) error {
+	return e.encodeInternedIndex(internedBytesExtID, idx)
+}
+
+// encodeInternedIndex writes the ext-encoded index shared by interned
+// strings and interned []byte values; only extID differs between the two.
+func (e *Encoder) encodeInternedIndex(extID int8, idx int) error {
 	if idx <= math.MaxUint8 {
 		if err := e.writeCode(msgpcode.FixExt1); err != nil {
 			return err
 		}
-		return e.write1(byte(internedStringExtID), uint8(idx))
+		return e.write1(byte(extID), uint8(idx))
 	}
 
 	if idx <= math.MaxUint16 {
 		if err := e.writeCode(msgpcode.FixExt2); err != nil {
 			return err
 		}
-		return e.write2(byte(internedStringExtID), uint16(idx))
+		return e.write2(byte(extID), uint16(idx))
 	}
 
 	if uint64(idx) <= math.MaxUint32 {
 		if err := e.writeCode(msgpcode.FixExt4); err != nil {
 			return err
 		}
-		return e.write4(byte(internedStringExtID), uint32(idx))
+		return e.write4(byte(extID), uint32(idx))
 	}
 
-	return fmt.Errorf("msgpack: intern string index=%d is too large", idx)
+	return fmt.Errorf("msgpack: intern index=%d is too large", idx)
 }
 
 //------------------------------------------------------------------------------
@@ -111,6 +586,56 @@ func decodeInternedInterfaceValue(d *Decoder, v reflect.Value) error {
 		return err
 	}
 
+	// An ext code here carries an interned string index, an interned []byte
+	// index, or a dictionary-reset record; dispatch on the ext type
+	// discriminator directly instead of trying each decoder in turn, since
+	// reading the ext header consumes it. A reset payload is 4+len(s) bytes,
+	// so (unlike a plain index) it can land on any ext size - check all of
+	// them, not just the ones an index alone would use.
+	switch c {
+	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4, msgpcode.FixExt8, msgpcode.FixExt16,
+		msgpcode.Ext8, msgpcode.Ext16, msgpcode.Ext32:
+		typeID, length, err := d.extHeader(c)
+		if err != nil {
+			return err
+		}
+
+		switch typeID {
+		case internedStringExtID:
+			idx, err := d.decodeInternedStringIndex(length)
+			if err != nil {
+				return err
+			}
+			s, err := d.internedStringAtIndex(idx)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(s))
+			return nil
+		case internedBytesExtID:
+			idx, err := d.decodeInternedStringIndex(length)
+			if err != nil {
+				return err
+			}
+			b, err := d.internedBytesAtIndex(idx)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(b))
+			return nil
+		case internedStringResetExtID:
+			s, err := d.decodeInternedStringReset(length)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(s))
+			return nil
+		}
+
+		return fmt.Errorf("msgpack: got ext type=%d, wanted %d, %d, or %d",
+			typeID, internedStringExtID, internedBytesExtID, internedStringResetExtID)
+	}
+
 	s, err := d.decodeInternedString(c, true)
 	if err == nil {
 		v.Set(reflect.ValueOf(s))
@@ -154,23 +679,31 @@ func (d *Decoder) decodeInternedString(c byte, intern bool) (string, error) {
 	switch c {
 	case msgpcode.Nil:
 		return "", nil
-	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4:
+	// The reset payload is 4+len(s) bytes, so unlike a plain index (which
+	// never exceeds FixExt4) it can land on any ext size - a 4 or 12 byte
+	// string name reset encodes as FixExt8/FixExt16 respectively. Dispatch
+	// on every ext code msgpack can emit, not just the ones an index alone
+	// would use.
+	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4, msgpcode.FixExt8, msgpcode.FixExt16,
+		msgpcode.Ext8, msgpcode.Ext16, msgpcode.Ext32:
 		typeID, length, err := d.extHeader(c)
 		if err != nil {
 			return "", err
 		}
-		if typeID != internedStringExtID {
-			err := fmt.Errorf("msgpack: got ext type=%d, wanted %d",
-				typeID, internedStringExtID)
-			return "", err
-		}
 
-		idx, err := d.decodeInternedStringIndex(length)
-		if err != nil {
-			return "", err
+		switch typeID {
+		case internedStringExtID:
+			idx, err := d.decodeInternedStringIndex(length)
+			if err != nil {
+				return "", err
+			}
+			return d.internedStringAtIndex(idx)
+		case internedStringResetExtID:
+			return d.decodeInternedStringReset(length)
+		default:
+			return "", fmt.Errorf("msgpack: got ext type=%d, wanted %d or %d",
+				typeID, internedStringExtID, internedStringResetExtID)
 		}
-
-		return d.internedStringAtIndex(idx)
 	case msgpcode.Str8, msgpcode.Bin8:
 		n, err := d.uint8()
 		if err != nil {
@@ -223,11 +756,49 @@ func (d *Decoder) decodeInternedStringIndex(length int) (int, error) {
 }
 
 func (d *Decoder) internedStringAtIndex(idx int) (string, error) {
-	if idx >= len(d.dict) {
-		err := fmt.Errorf("msgpack: intern string with index=%d does not exist", idx)
+	s, ok := d.dict.Get(idx)
+	if !ok {
+		return "", fmt.Errorf("msgpack: intern string with index=%d does not exist", idx)
+	}
+	return s, nil
+}
+
+func decodeInternedStringResetExt(d *Decoder, v reflect.Value, extLen int) error {
+	s, err := d.decodeInternedStringReset(extLen)
+	if err != nil {
+		return err
+	}
+
+	v.SetString(s)
+	return nil
+}
+
+// decodeInternedStringReset applies a dictionary reset record written by
+// (*Encoder).encodeInternedStringReset, rewriting d.dict at the reused
+// index so it matches the encoder's eviction decision.
+func (d *Decoder) decodeInternedStringReset(extLen int) (string, error) {
+	if extLen < 4 {
+		return "", fmt.Errorf("msgpack: short intern string reset record len=%d", extLen)
+	}
+
+	b, err := d.readN(extLen)
+	if err != nil {
 		return "", err
 	}
-	return d.dict[idx], nil
+
+	idx := int(binary.BigEndian.Uint32(b[:4]))
+	if idx < 0 || idx >= d.internMaxDictOrDefault() {
+		return "", fmt.Errorf("msgpack: intern string reset index=%d exceeds max dict size=%d",
+			idx, d.internMaxDictOrDefault())
+	}
+	s := string(b[4:])
+
+	if d.dict == nil {
+		d.dict = NewLocalDecoderDict()
+	}
+	d.dict.Set(idx, s)
+
+	return s, nil
 }
 
 func (d *Decoder) decodeInternedStringWithLen(n int, intern bool) (string, error) {
@@ -240,9 +811,126 @@ func (d *Decoder) decodeInternedStringWithLen(n int, intern bool) (string, error
 		return "", err
 	}
 
-	if intern && len(s) >= minInternedStringLen && len(d.dict) < maxDictLen {
-		d.dict = append(d.dict, s)
+	if intern && len(s) >= d.internMinLenOrDefault() {
+		if d.dict == nil {
+			d.dict = NewLocalDecoderDict()
+		}
+		if d.dict.Len() < d.internMaxDictOrDefault() {
+			d.dict.Append(s)
+		}
 	}
 
 	return s, nil
 }
+
+func (d *Decoder) internMinLenOrDefault() int {
+	if d.internMinLen > 0 {
+		return d.internMinLen
+	}
+	return minInternedStringLen
+}
+
+func (d *Decoder) internMaxDictOrDefault() int {
+	if d.internMaxDict > 0 {
+		return d.internMaxDict
+	}
+	return maxDictLen
+}
+
+// SetInternMinLen overrides minInternedStringLen for d, mirroring
+// (*Encoder).SetInternMinLen. Pass 0 to restore the package default.
+func (d *Decoder) SetInternMinLen(n int) {
+	d.internMinLen = n
+}
+
+// SetInternMaxDict overrides maxDictLen for d, mirroring
+// (*Encoder).SetInternMaxDict. Pass 0 to restore the package default.
+func (d *Decoder) SetInternMaxDict(n int) {
+	d.internMaxDict = n
+}
+
+func decodeInternedBytesValue(d *Decoder, v reflect.Value) error {
+	c, err := d.readCode()
+	if err != nil {
+		return err
+	}
+
+	b, err := d.decodeInternedBytes(c, true)
+	if err != nil {
+		if err == errUnexpectedCode {
+			return fmt.Errorf("msgpack: invalid code=%x decoding intern bytes", c)
+		}
+		return err
+	}
+
+	v.SetBytes(b)
+	return nil
+}
+
+func (d *Decoder) decodeInternedBytes(c byte, intern bool) ([]byte, error) {
+	switch c {
+	case msgpcode.Nil:
+		return nil, nil
+	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4:
+		typeID, length, err := d.extHeader(c)
+		if err != nil {
+			return nil, err
+		}
+		if typeID != internedBytesExtID {
+			return nil, fmt.Errorf("msgpack: got ext type=%d, wanted %d",
+				typeID, internedBytesExtID)
+		}
+
+		idx, err := d.decodeInternedStringIndex(length)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.internedBytesAtIndex(idx)
+	case msgpcode.Bin8:
+		n, err := d.uint8()
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeInternedBytesWithLen(int(n), intern)
+	case msgpcode.Bin16:
+		n, err := d.uint16()
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeInternedBytesWithLen(int(n), intern)
+	case msgpcode.Bin32:
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeInternedBytesWithLen(int(n), intern)
+	}
+
+	return nil, errUnexpectedCode
+}
+
+func (d *Decoder) internedBytesAtIndex(idx int) ([]byte, error) {
+	if idx >= len(d.bdict) {
+		err := fmt.Errorf("msgpack: interned bytes with index=%d does not exist", idx)
+		return nil, err
+	}
+	return d.bdict[idx], nil
+}
+
+func (d *Decoder) decodeInternedBytesWithLen(n int, intern bool) ([]byte, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	b, err := d.bytesWithLen(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if intern && len(b) >= d.internMinLenOrDefault() && len(d.bdict) < d.internMaxDictOrDefault() {
+		d.bdict = append(d.bdict, b)
+	}
+
+	return b, nil
+}
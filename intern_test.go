@@ -0,0 +1,186 @@
+package msgpack
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+func TestInternCacheEvictionReset(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetInternCache(2, NewLRUPolicy())
+
+	words := []string{"alpha", "beta", "alpha", "gamma", "beta", "gamma"}
+	for _, w := range words {
+		if err := enc.encodeInternedString(w, true); err != nil {
+			t.Fatalf("encode %q: %v", w, err)
+		}
+	}
+
+	dec := NewDecoder(&buf)
+	for i, want := range words {
+		c, err := dec.readCode()
+		if err != nil {
+			t.Fatalf("read code %d: %v", i, err)
+		}
+		got, err := dec.decodeInternedString(c, true)
+		if err != nil {
+			t.Fatalf("decode %d (want %q): %v", i, want, err)
+		}
+		if got != want {
+			t.Fatalf("word %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestSharedDictRoundTrip(t *testing.T) {
+	shared := NewDictionary()
+	shared.Add("alpha")
+	shared.Add("beta")
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetSharedDict(shared)
+
+	words := []string{"alpha", "beta", "alpha"}
+	for _, w := range words {
+		if err := enc.encodeInternedString(w, true); err != nil {
+			t.Fatalf("encode %q: %v", w, err)
+		}
+	}
+
+	// Both sides already agree on shared's contents, so even the very first
+	// occurrence of "alpha" must be written as an index reference, not a
+	// plain string.
+	if got := buf.Bytes()[0]; got != msgpcode.FixExt1 {
+		t.Fatalf("first byte = %#x, want FixExt1 (%#x): shared dict wasn't used", got, msgpcode.FixExt1)
+	}
+
+	dec := NewDecoder(&buf)
+	dec.SetSharedDict(shared)
+	for i, want := range words {
+		c, err := dec.readCode()
+		if err != nil {
+			t.Fatalf("read code %d: %v", i, err)
+		}
+		got, err := dec.decodeInternedString(c, true)
+		if err != nil {
+			t.Fatalf("decode %d (want %q): %v", i, want, err)
+		}
+		if got != want {
+			t.Fatalf("word %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+// fixedEncoderDict is a minimal custom EncoderDict used to verify that
+// (*Encoder).SetDict's pluggable backend round-trips independently of
+// localEncoderDict.
+type fixedEncoderDict struct {
+	m map[string]int
+}
+
+func (d *fixedEncoderDict) Intern(s string) (int, bool) {
+	if idx, ok := d.m[s]; ok {
+		return idx, true
+	}
+	idx := len(d.m)
+	d.m[s] = idx
+	return idx, false
+}
+
+func (d *fixedEncoderDict) Lookup(s string) (int, bool) {
+	idx, ok := d.m[s]
+	return idx, ok
+}
+
+func (d *fixedEncoderDict) Len() int {
+	return len(d.m)
+}
+
+// fixedDecoderDict is fixedEncoderDict's decode-side counterpart.
+type fixedDecoderDict struct {
+	s []string
+}
+
+func (d *fixedDecoderDict) Append(s string) int {
+	idx := len(d.s)
+	d.s = append(d.s, s)
+	return idx
+}
+
+func (d *fixedDecoderDict) Get(idx int) (string, bool) {
+	if idx < 0 || idx >= len(d.s) {
+		return "", false
+	}
+	return d.s[idx], true
+}
+
+func (d *fixedDecoderDict) Set(idx int, s string) {
+	for len(d.s) <= idx {
+		d.s = append(d.s, "")
+	}
+	d.s[idx] = s
+}
+
+func (d *fixedDecoderDict) Len() int {
+	return len(d.s)
+}
+
+func TestCustomDictRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetDict(&fixedEncoderDict{m: make(map[string]int)})
+
+	words := []string{"alpha", "beta", "alpha", "gamma"}
+	for _, w := range words {
+		if err := enc.encodeInternedString(w, true); err != nil {
+			t.Fatalf("encode %q: %v", w, err)
+		}
+	}
+
+	dec := NewDecoder(&buf)
+	dec.SetDict(&fixedDecoderDict{})
+	for i, want := range words {
+		c, err := dec.readCode()
+		if err != nil {
+			t.Fatalf("read code %d: %v", i, err)
+		}
+		got, err := dec.decodeInternedString(c, true)
+		if err != nil {
+			t.Fatalf("decode %d (want %q): %v", i, want, err)
+		}
+		if got != want {
+			t.Fatalf("word %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestInternedBytesRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+
+	payloads := [][]byte{[]byte("deadbeef"), []byte("cafebabe"), []byte("deadbeef")}
+	for _, p := range payloads {
+		if err := enc.encodeInternedBytes(p, true); err != nil {
+			t.Fatalf("encode %q: %v", p, err)
+		}
+	}
+
+	dec := NewDecoder(&buf)
+	for i, want := range payloads {
+		c, err := dec.readCode()
+		if err != nil {
+			t.Fatalf("read code %d: %v", i, err)
+		}
+		got, err := dec.decodeInternedBytes(c, true)
+		if err != nil {
+			t.Fatalf("decode %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("payload %d: got %q, want %q", i, got, want)
+		}
+	}
+}